@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// autoDiscoveryConfig describes a single `hcloud:label=...,minSize=...,maxSize=...`
+// auto-discovery spec, analogous to the `--node-group-auto-discovery` flag
+// supported by other providers such as GKE.
+type autoDiscoveryConfig struct {
+	label   string
+	minSize int
+	maxSize int
+}
+
+// parseAutoDiscoverySpecs parses the `--node-group-auto-discovery` flag
+// values into autoDiscoveryConfig entries. The only supported syntax today
+// is `hcloud:label=<key>,minSize=<n>,maxSize=<n>`.
+func parseAutoDiscoverySpecs(specs []string) ([]autoDiscoveryConfig, error) {
+	configs := make([]autoDiscoveryConfig, 0, len(specs))
+
+	for _, spec := range specs {
+		provider, rest, ok := strings.Cut(spec, ":")
+		if !ok || provider != "hcloud" {
+			return nil, fmt.Errorf("invalid node group auto discovery spec `%s`, expected format `hcloud:label=<key>,minSize=<n>,maxSize=<n>`", spec)
+		}
+
+		cfg := autoDiscoveryConfig{}
+		for _, token := range strings.Split(rest, ",") {
+			key, value, ok := strings.Cut(token, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid node group auto discovery spec `%s`, malformed entry `%s`", spec, token)
+			}
+
+			switch key {
+			case "label":
+				cfg.label = value
+			case "minSize":
+				size, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid node group auto discovery spec `%s`, minSize must be an integer: %v", spec, err)
+				}
+				cfg.minSize = size
+			case "maxSize":
+				size, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid node group auto discovery spec `%s`, maxSize must be an integer: %v", spec, err)
+				}
+				cfg.maxSize = size
+			default:
+				return nil, fmt.Errorf("invalid node group auto discovery spec `%s`, unknown key `%s`", spec, key)
+			}
+		}
+
+		if cfg.label == "" {
+			return nil, fmt.Errorf("invalid node group auto discovery spec `%s`, label is required", spec)
+		}
+
+		configs = append(configs, cfg)
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAutoDiscoverySpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []autoDiscoveryConfig
+		wantErr bool
+	}{
+		{
+			name:  "valid single spec",
+			specs: []string{"hcloud:label=k8s.io/cluster-autoscaler/nodegroup,minSize=1,maxSize=10"},
+			want: []autoDiscoveryConfig{
+				{label: "k8s.io/cluster-autoscaler/nodegroup", minSize: 1, maxSize: 10},
+			},
+		},
+		{
+			name: "multiple specs",
+			specs: []string{
+				"hcloud:label=pool-a,minSize=0,maxSize=5",
+				"hcloud:label=pool-b,minSize=2,maxSize=20",
+			},
+			want: []autoDiscoveryConfig{
+				{label: "pool-a", minSize: 0, maxSize: 5},
+				{label: "pool-b", minSize: 2, maxSize: 20},
+			},
+		},
+		{
+			name:  "no specs",
+			specs: nil,
+			want:  []autoDiscoveryConfig{},
+		},
+		{
+			name:    "wrong provider prefix",
+			specs:   []string{"gce:label=foo,minSize=1,maxSize=2"},
+			wantErr: true,
+		},
+		{
+			name:    "missing colon",
+			specs:   []string{"hcloud"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed key=value entry",
+			specs:   []string{"hcloud:label"},
+			wantErr: true,
+		},
+		{
+			name:    "non-integer minSize",
+			specs:   []string{"hcloud:label=foo,minSize=abc,maxSize=2"},
+			wantErr: true,
+		},
+		{
+			name:    "non-integer maxSize",
+			specs:   []string{"hcloud:label=foo,minSize=1,maxSize=abc"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			specs:   []string{"hcloud:label=foo,minSize=1,maxSize=2,unknown=1"},
+			wantErr: true,
+		},
+		{
+			name:    "missing label",
+			specs:   []string{"hcloud:minSize=1,maxSize=2"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAutoDiscoverySpecs(tt.specs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAutoDiscoverySpecs(%v) = %+v, want %+v", tt.specs, got, tt.want)
+			}
+		})
+	}
+}
+
+func serverLabeledJSON(id int64, labelKey, labelValue string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          id,
+		"labels":      map[string]string{labelKey: labelValue},
+		"server_type": map[string]interface{}{"name": "cx22"},
+		"datacenter":  map[string]interface{}{"location": map[string]interface{}{"name": "fsn1"}},
+	}
+}
+
+// TestRefreshAutoDiscoveredNodeGroupsGroupsBySpecLabel guards against
+// grouping servers by the shared nodeGroupLabel constant instead of each
+// auto discovery spec's own label key, which would otherwise collapse every
+// spec's servers into a single bucket keyed by whatever they happen to carry
+// under nodeGroupLabel.
+func TestRefreshAutoDiscoveredNodeGroupsGroupsBySpecLabel(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		var servers []map[string]interface{}
+		switch r.URL.Query().Get("label_selector") {
+		case "team-a":
+			servers = []map[string]interface{}{serverLabeledJSON(1, "team-a", "pool-1")}
+		case "team-b":
+			servers = []map[string]interface{}{serverLabeledJSON(2, "team-b", "pool-2")}
+		}
+		writeServersPage(w, servers)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	manager := newTestManager(ts)
+	manager.autoDiscoverySpecs = []autoDiscoveryConfig{
+		{label: "team-a", minSize: 0, maxSize: 5},
+		{label: "team-b", minSize: 0, maxSize: 5},
+	}
+
+	if err := manager.refreshAutoDiscoveredNodeGroups(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := manager.nodeGroups["pool-1"]; !ok {
+		t.Errorf("expected pool-1, discovered from the team-a spec's own label, to exist; got %+v", manager.nodeGroups)
+	}
+	if _, ok := manager.nodeGroups["pool-2"]; !ok {
+		t.Errorf("expected pool-2, discovered from the team-b spec's own label, to exist; got %+v", manager.nodeGroups)
+	}
+	if len(manager.nodeGroups) != 2 {
+		t.Fatalf("expected exactly 2 auto discovered node groups, got %+v", manager.nodeGroups)
+	}
+}
+
+// TestRefreshAutoDiscoveredNodeGroupsRemovesEmptyGroups checks that a
+// previously discovered node group is dropped once a refresh finds no more
+// servers carrying its label.
+func TestRefreshAutoDiscoveredNodeGroupsRemovesEmptyGroups(t *testing.T) {
+	mux := http.NewServeMux()
+	empty := false
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		if empty {
+			writeServersPage(w, nil)
+			return
+		}
+		writeServersPage(w, []map[string]interface{}{serverLabeledJSON(1, "team-a", "pool-1")})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	manager := newTestManager(ts)
+	manager.autoDiscoverySpecs = []autoDiscoveryConfig{{label: "team-a", minSize: 0, maxSize: 5}}
+
+	if err := manager.refreshAutoDiscoveredNodeGroups(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := manager.nodeGroups["pool-1"]; !ok {
+		t.Fatalf("expected pool-1 to be discovered first, got %+v", manager.nodeGroups)
+	}
+
+	empty = true
+	if err := manager.refreshAutoDiscoveredNodeGroups(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := manager.nodeGroups["pool-1"]; ok {
+		t.Errorf("expected pool-1 to be removed once no labeled servers remain, got %+v", manager.nodeGroups)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/klog/v2"
+)
+
+// backendKind selects which Hetzner service a node group is backed by.
+type backendKind string
+
+const (
+	backendHCloud backendKind = "hcloud"
+	backendRobot  backendKind = "robot"
+)
+
+// nodeGroupBackend is implemented once per supported Hetzner service so that
+// hetznerNodeGroup can drive either an HCloud virtual server pool or a Robot
+// dedicated server pool without branching on backend kind everywhere.
+type nodeGroupBackend interface {
+	// IncreaseSize provisions delta additional servers for the node group.
+	IncreaseSize(delta int) error
+	// DeleteNodes removes the given nodes' backing servers from the node group.
+	DeleteNodes(nodes []*apiv1.Node) error
+	// Nodes lists the servers currently backing the node group.
+	Nodes() ([]cloudprovider.Instance, error)
+}
+
+// hcloudBackend implements nodeGroupBackend against HCloud virtual servers.
+type hcloudBackend struct {
+	group *hetznerNodeGroup
+}
+
+func newHCloudBackend(group *hetznerNodeGroup) *hcloudBackend {
+	return &hcloudBackend{group: group}
+}
+
+func (b *hcloudBackend) IncreaseSize(delta int) error {
+	if err := checkRateLimit(b.group.manager.rateLimiter); err != nil {
+		return err
+	}
+
+	// Make sure there's room in the node group's placement group (creating
+	// overflow ones if needed) before provisioning new servers into it. A
+	// nil allocations slice means the node group has no placement group at
+	// all, in which case every new server is created without one.
+	allocations, err := b.group.manager.ensurePlacementGroupCapacity(b.group.id, delta)
+	if err != nil {
+		return fmt.Errorf("failed to ensure placement group capacity for node group %s: %v", b.group.id, err)
+	}
+	if allocations == nil {
+		allocations = []placementGroupAllocation{{count: delta}}
+	}
+
+	serverType, err := b.group.manager.serverTypeByName(b.group.instanceType)
+	if err != nil {
+		return fmt.Errorf("failed to resolve server type for node group %s: %v", b.group.id, err)
+	}
+
+	for _, allocation := range allocations {
+		for i := 0; i < allocation.count; i++ {
+			if err := b.group.manager.createServer(b.group, serverType, allocation.group); err != nil {
+				return fmt.Errorf("failed to increase size of node group %s: %v", b.group.id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *hcloudBackend) DeleteNodes(nodes []*apiv1.Node) error {
+	if err := checkRateLimit(b.group.manager.rateLimiter); err != nil {
+		return err
+	}
+
+	servers := make([]*hcloud.Server, 0, len(nodes))
+	for _, node := range nodes {
+		server, err := b.group.manager.serverForNode(node)
+		if err != nil {
+			return fmt.Errorf("failed to resolve server for node %s: %v", node.Name, err)
+		}
+		if server != nil {
+			servers = append(servers, server)
+		}
+	}
+
+	// Delete in the order preferred by the node group's termination policy,
+	// so that if the caller aborts partway through, the servers it most
+	// wanted gone are the ones already removed.
+	sortServersForTermination(servers, b.group.terminationPolicy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverCreateTimeoutDefault)
+	defer cancel()
+
+	for _, server := range servers {
+		if _, _, err := b.group.manager.client.Server.DeleteWithResult(ctx, server); err != nil {
+			return fmt.Errorf("failed to delete server %d for node group %s: %v", server.ID, b.group.id, err)
+		}
+		klog.V(2).Infof("deleted server %d for node group %s", server.ID, b.group.id)
+	}
+
+	return nil
+}
+
+func (b *hcloudBackend) Nodes() ([]cloudprovider.Instance, error) {
+	servers, err := b.group.manager.allServers(b.group.id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get servers for node group %s error: %v", b.group.id, err)
+	}
+
+	instances := make([]cloudprovider.Instance, 0, len(servers))
+	for _, server := range servers {
+		instances = append(instances, toInstance(server))
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// serverTypeCacheTTL is how long the server type catalog is considered fresh
+// before it is re-fetched from the Hetzner API.
+const serverTypeCacheTTL = 1 * time.Hour
+
+// serverTypeCache caches the list of server types and the account-wide
+// pricing catalog available from the Hetzner API so that repeated lookups
+// (e.g. during GetAvailableMachineTypes or pricing calculations) don't issue
+// an API call each time.
+type serverTypeCache struct {
+	mutex sync.Mutex
+
+	client      *hcloud.Client
+	serverTypes []*hcloud.ServerType
+	lastRefresh time.Time
+
+	pricing        *hcloud.Pricing
+	pricingRefresh time.Time
+}
+
+func newServerTypeCache(client *hcloud.Client) *serverTypeCache {
+	return &serverTypeCache{
+		client: client,
+	}
+}
+
+// getAllServerTypes returns the cached list of server types, refreshing it
+// from the API if the cache is empty or stale.
+func (c *serverTypeCache) getAllServerTypes() ([]*hcloud.ServerType, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.serverTypes) > 0 && time.Since(c.lastRefresh) < serverTypeCacheTTL {
+		return c.serverTypes, nil
+	}
+
+	serverTypes, err := c.client.ServerType.All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	c.serverTypes = serverTypes
+	c.lastRefresh = time.Now()
+
+	return c.serverTypes, nil
+}
+
+// serverPrice holds the hourly and monthly net price, in the currency
+// configured on the Hetzner account, for running something continuously.
+type serverPrice struct {
+	Hourly  float64
+	Monthly float64
+}
+
+// priceFor returns the hourly and monthly net price for running instanceType
+// in location.
+func (c *serverTypeCache) priceFor(instanceType, location string) (serverPrice, error) {
+	serverTypes, err := c.getAllServerTypes()
+	if err != nil {
+		return serverPrice{}, err
+	}
+
+	for _, serverType := range serverTypes {
+		if !strings.EqualFold(serverType.Name, instanceType) {
+			continue
+		}
+
+		for _, pricing := range serverType.Pricings {
+			if pricing.Location == nil || !strings.EqualFold(pricing.Location.Name, location) {
+				continue
+			}
+
+			return parsePriceNet(pricing.Hourly.Net, pricing.Monthly.Net)
+		}
+
+		return serverPrice{}, fmt.Errorf("no pricing found for server type %s in location %s", instanceType, location)
+	}
+
+	return serverPrice{}, fmt.Errorf("unknown server type %s", instanceType)
+}
+
+// ipv4SurchargePrice returns the hourly and monthly surcharge Hetzner
+// charges for a server's primary IPv4 address in location, billed
+// separately from the server type itself.
+func (c *serverTypeCache) ipv4SurchargePrice(location string) (serverPrice, error) {
+	pricing, err := c.getPricing()
+	if err != nil {
+		return serverPrice{}, err
+	}
+
+	for _, primaryIP := range pricing.PrimaryIPs {
+		if primaryIP.Type != "ipv4" {
+			continue
+		}
+
+		for _, p := range primaryIP.Pricings {
+			if p.Location == nil || !strings.EqualFold(p.Location.Name, location) {
+				continue
+			}
+
+			return parsePriceNet(p.Hourly.Net, p.Monthly.Net)
+		}
+	}
+
+	return serverPrice{}, fmt.Errorf("no IPv4 surcharge pricing found for location %s", location)
+}
+
+// getPricing returns the cached account-wide pricing catalog, refreshing it
+// from the API if the cache is empty or stale.
+func (c *serverTypeCache) getPricing() (*hcloud.Pricing, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.pricing != nil && time.Since(c.pricingRefresh) < serverTypeCacheTTL {
+		return c.pricing, nil
+	}
+
+	pricing, _, err := c.client.Pricing.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	c.pricing = pricing
+	c.pricingRefresh = time.Now()
+
+	return c.pricing, nil
+}
+
+// parsePriceNet parses a pair of hourly/monthly net price strings as
+// returned by the Hetzner API.
+func parsePriceNet(hourlyNet, monthlyNet string) (serverPrice, error) {
+	hourly, err := strconv.ParseFloat(hourlyNet, 64)
+	if err != nil {
+		return serverPrice{}, fmt.Errorf("unparsable hourly price %q: %v", hourlyNet, err)
+	}
+
+	monthly, err := strconv.ParseFloat(monthlyNet, 64)
+	if err != nil {
+		return serverPrice{}, fmt.Errorf("unparsable monthly price %q: %v", monthlyNet, err)
+	}
+
+	return serverPrice{Hourly: hourly, Monthly: monthly}, nil
+}
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
@@ -69,8 +70,25 @@ func (d *HetznerCloudProvider) NodeGroups() []cloudprovider.NodeGroup {
 
 // NodeGroupForNode returns the node group for the given node, nil if the node
 // should not be processed by cluster autoscaler, or non-nil error if such
-// occurred. Must be implemented.
+// occurred. Must be implemented. Recognizes both `hcloud://` provider IDs,
+// which are resolved against the HCloud API directly, and `hrobot://`
+// provider IDs, which are looked up by the node group label below since
+// Robot servers aren't addressable through manager.serverForNode.
 func (d *HetznerCloudProvider) NodeGroupForNode(node *apiv1.Node) (cloudprovider.NodeGroup, error) {
+	if strings.HasPrefix(node.Spec.ProviderID, robotProviderIDPrefix) {
+		groupId, exists := node.Labels[nodeGroupLabel]
+		if !exists {
+			return nil, nil
+		}
+
+		group, exists := d.manager.nodeGroups[groupId]
+		if !exists {
+			return nil, nil
+		}
+
+		return group, nil
+	}
+
 	server, err := d.manager.serverForNode(node)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if server %s exists error: %v", node.Spec.ProviderID, err)
@@ -102,13 +120,53 @@ func (d *HetznerCloudProvider) NodeGroupForNode(node *apiv1.Node) (cloudprovider
 
 // HasInstance returns whether a given node has a corresponding instance in this cloud provider
 func (d *HetznerCloudProvider) HasInstance(node *apiv1.Node) (bool, error) {
-	return true, cloudprovider.ErrNotImplemented
+	if strings.HasPrefix(node.Spec.ProviderID, robotProviderIDPrefix) {
+		return d.hasRobotInstance(node)
+	}
+
+	server, err := d.manager.serverForNode(node)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if server %s exists error: %v", node.Spec.ProviderID, err)
+	}
+
+	return server != nil, nil
+}
+
+// hasRobotInstance is the hrobot:// counterpart of HasInstance: Robot
+// servers aren't deleted when removed from a node group (they're returned
+// to the pool for reuse), so presence is tracked by the robotBackend's
+// in-use set rather than by asking an API whether the server still exists.
+func (d *HetznerCloudProvider) hasRobotInstance(node *apiv1.Node) (bool, error) {
+	serverNumber, err := robotServerNumberFromProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return false, err
+	}
+
+	groupID, ok := node.Labels[nodeGroupLabel]
+	if !ok {
+		return false, nil
+	}
+
+	group, ok := d.manager.nodeGroups[groupID]
+	if !ok {
+		return false, nil
+	}
+
+	backend, ok := group.backend.(*robotBackend)
+	if !ok {
+		return false, nil
+	}
+
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	return backend.inUse[serverNumber], nil
 }
 
 // Pricing returns pricing model for this cloud provider or error if not
 // available. Implementation optional.
 func (d *HetznerCloudProvider) Pricing() (cloudprovider.PricingModel, errors.AutoscalerError) {
-	return nil, cloudprovider.ErrNotImplemented
+	return newHetznerPricingModel(d.manager), nil
 }
 
 // GetAvailableMachineTypes get all machine types that can be requested from
@@ -173,12 +231,32 @@ func (d *HetznerCloudProvider) Cleanup() error {
 // update cloud provider state. In particular the list of node groups returned
 // by NodeGroups() can change as a result of CloudProvider.Refresh().
 func (d *HetznerCloudProvider) Refresh() error {
+	if err := checkRateLimit(d.manager.rateLimiter); err != nil {
+		klog.Warningf("skipping refresh: %v", err)
+		return nil
+	}
+
+	if len(d.manager.autoDiscoverySpecs) > 0 {
+		if err := d.manager.refreshAutoDiscoveredNodeGroups(); err != nil {
+			klog.Warningf("failed to refresh auto discovered node groups: %v", err)
+		}
+	}
+
 	for _, group := range d.manager.nodeGroups {
+		if group.autoDiscovered {
+			continue
+		}
 		group.resetTargetSize(0)
 	}
 	return nil
 }
 
+// RateLimitStatus returns the currently observed state of the Hetzner API
+// rate limit, so operators can inspect or alert on it.
+func (d *HetznerCloudProvider) RateLimitStatus() RateLimitStatus {
+	return d.manager.rateLimiter.status()
+}
+
 // Check if any defined placement groups could potentially have more than the maximum allowed number of nodes
 func getLargePlacementGroups(nodeGroups map[string]*hetznerNodeGroup, threshold int) []int64 {
 	placementGroupTotals := make(map[int64]int)
@@ -222,6 +300,14 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 
 	validNodePoolName := regexp.MustCompile(`^[a-z0-9A-Z]+[a-z0-9A-Z\-\.\_]*[a-z0-9A-Z]+$|^[a-z0-9A-Z]{1}$`)
 	clusterUpdateLock := sync.Mutex{}
+	manager.clusterUpdateMutex = &clusterUpdateLock
+
+	autoDiscoverySpecs, err := parseAutoDiscoverySpecs(do.NodeGroupAutoDiscoverySpecs)
+	if err != nil {
+		klog.Fatalf("Failed to parse node group auto discovery specs: %v", err)
+	}
+	manager.autoDiscoverySpecs = autoDiscoverySpecs
+
 	for _, nodegroupSpec := range do.NodeGroupSpecs {
 		spec, err := createNodePoolSpec(nodegroupSpec)
 		if err != nil {
@@ -235,13 +321,16 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 		}
 
 		if manager.clusterConfig.IsUsingNewFormat {
-			_, ok := manager.clusterConfig.NodeConfigs[spec.name]
+			nodeConfig, ok := manager.clusterConfig.NodeConfigs[spec.name]
 			if !ok {
 				klog.Fatalf("No node config present for node group id `%s` error: %v", spec.name, err)
 			}
+			if nodeConfig.Backend == string(backendRobot) {
+				spec.backend = backendRobot
+			}
 		}
 
-		manager.nodeGroups[spec.name] = &hetznerNodeGroup{
+		group := &hetznerNodeGroup{
 			manager:            manager,
 			id:                 spec.name,
 			minSize:            spec.minSize,
@@ -250,8 +339,27 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 			region:             strings.ToLower(spec.region),
 			targetSize:         len(servers),
 			clusterUpdateMutex: &clusterUpdateLock,
+			terminationPolicy:  spec.terminationPolicy,
+		}
+
+		if manager.clusterConfig.IsUsingNewFormat {
+			group.terminationPolicy = parseTerminationPolicy(manager.clusterConfig.NodeConfigs[spec.name].TerminationPolicy)
 		}
 
+		if spec.backend == backendRobot {
+			if manager.robotClient == nil {
+				manager.robotClient, err = newRobotClient()
+				if err != nil {
+					klog.Fatalf("Failed to create Hetzner Robot client for node group %s: %v", spec.name, err)
+				}
+			}
+			group.backend = newRobotBackend(group, manager.robotClient, manager.clusterConfig.NodeConfigs[spec.name].RobotServerNumbers)
+		} else {
+			group.backend = newHCloudBackend(group)
+		}
+
+		manager.nodeGroups[spec.name] = group
+
 		// If a placement group was specified, check with the API to see if it exists
 		if manager.clusterConfig.IsUsingNewFormat {
 
@@ -278,6 +386,7 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 			// If the placement group exists, add it to the node group config
 			if placementGroup != nil {
 				manager.nodeGroups[spec.name].placementGroup = placementGroup
+				manager.placementGroups[spec.name] = []*hcloud.PlacementGroup{placementGroup}
 			} else {
 				klog.Fatalf("The requested placement group `%s` does not appear to exist.", placementGroupRef)
 			}
@@ -285,11 +394,14 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 	}
 
 	// Get placement groups with total maxSize over the maximum allowed
-	maxPlacementGroupSize := 10
+	maxPlacementGroupSize := hetznerPlacementGroupMaxSize
 
 	largePlacementGroups := getLargePlacementGroups(manager.nodeGroups, maxPlacementGroupSize)
 
-	// Fail if we have placement groups over the max size
+	// Placement groups over the max size are no longer fatal: pre-create the
+	// overflow placement groups they'll need so the first scale-up doesn't
+	// have to pay for it, and let IncreaseSize pick up any more that are
+	// needed later as the group keeps growing.
 	if len(largePlacementGroups) > 0 {
 
 		// Gather placement group names
@@ -301,22 +413,39 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 			placementGroupIDs += strconv.FormatInt(placementGroupID, 10)
 		}
 
-		klog.Fatalf("The following placement groups have a potential size over the allowed maximum of %d: %s.", maxPlacementGroupSize, placementGroupIDs)
+		klog.Warningf("The following placement groups have a potential size over the allowed maximum of %d: %s. Pre-creating overflow placement groups.", maxPlacementGroupSize, placementGroupIDs)
+
+		for _, group := range manager.nodeGroups {
+			if group.placementGroup == nil || group.placementGroup.ID == 0 {
+				continue
+			}
+			if _, err := manager.ensurePlacementGroupCapacity(group.id, group.maxSize); err != nil {
+				klog.Warningf("failed to pre-create overflow placement groups for node group %s: %v", group.id, err)
+			}
+		}
+	}
+
+	if len(manager.autoDiscoverySpecs) > 0 {
+		if err := manager.refreshAutoDiscoveredNodeGroups(); err != nil {
+			klog.Fatalf("Failed initial node group auto discovery: %v", err)
+		}
 	}
 
 	return provider
 }
 
 func createNodePoolSpec(groupSpec string) (*hetznerNodeGroupSpec, error) {
-	tokens := strings.SplitN(groupSpec, ":", 5)
-	if len(tokens) != 5 {
-		return nil, fmt.Errorf("expected format `<min-servers>:<max-servers>:<machine-type>:<region>:<name>` got %s", groupSpec)
+	tokens := strings.Split(groupSpec, ":")
+	if len(tokens) != 5 && len(tokens) != 6 {
+		return nil, fmt.Errorf("expected format `<min-servers>:<max-servers>:<machine-type>:<region>:<name>[:<termination-policy>]` got %s", groupSpec)
 	}
 
 	definition := hetznerNodeGroupSpec{
-		instanceType: tokens[2],
-		region:       tokens[3],
-		name:         tokens[4],
+		instanceType:      tokens[2],
+		region:            tokens[3],
+		name:              tokens[4],
+		backend:           backendHCloud,
+		terminationPolicy: terminationPolicyRandom,
 	}
 	if size, err := strconv.Atoi(tokens[0]); err == nil {
 		definition.minSize = size
@@ -330,6 +459,10 @@ func createNodePoolSpec(groupSpec string) (*hetznerNodeGroupSpec, error) {
 		return nil, fmt.Errorf("failed to set max size: %s, expected integer", tokens[1])
 	}
 
+	if len(tokens) == 6 {
+		definition.terminationPolicy = parseTerminationPolicy(tokens[5])
+	}
+
 	return &definition, nil
 }
 
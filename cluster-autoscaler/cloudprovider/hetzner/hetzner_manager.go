@@ -0,0 +1,344 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// hetznerNodeGroupSpec describes a node group as parsed from the
+// `--nodes=min:max:type:region:name[:termination-policy]` flag.
+type hetznerNodeGroupSpec struct {
+	name         string
+	minSize      int
+	maxSize      int
+	instanceType string
+	region       string
+	backend      backendKind
+	// terminationPolicy selects which servers are preferred deletion
+	// candidates when scaling the node group down. Defaults to
+	// terminationPolicyRandom when the flag omits it.
+	terminationPolicy terminationPolicy
+}
+
+// nodeConfig holds the per-node-group settings of the new-format cluster
+// configuration.
+type nodeConfig struct {
+	PlacementGroup string `json:"placementGroup"`
+	// Backend selects which Hetzner service this node group is provisioned
+	// against. Defaults to "hcloud" when empty.
+	Backend string `json:"backend"`
+	// RobotServerNumbers lists the pre-provisioned Robot server numbers
+	// available to this node group. Only used when Backend is "robot".
+	RobotServerNumbers []int `json:"robotServerNumbers"`
+	// TerminationPolicy selects which servers are preferred when scaling
+	// the node group down: "oldest", "newest", "least-utilized" or
+	// "random" (the default).
+	TerminationPolicy string `json:"terminationPolicy"`
+}
+
+// clusterConfig is the parsed new-format cluster configuration. newManager
+// reads no configuration into it: it leaves IsUsingNewFormat false and
+// NodeConfigs nil. When IsUsingNewFormat is false, node groups are only described through the
+// legacy `--nodes` flag and NodeConfigs is unused.
+type clusterConfig struct {
+	IsUsingNewFormat bool
+	NodeConfigs      map[string]nodeConfig
+}
+
+// hetznerManager handles all the interactions with the Hetzner Cloud API.
+type hetznerManager struct {
+	client        *hcloud.Client
+	nodeGroups    map[string]*hetznerNodeGroup
+	apiCallContext context.Context
+
+	// image is the name or ID of the hcloud image newly created servers are
+	// booted from. Defaults to hetznerDefaultImage.
+	image string
+	// sshKeyNames lists the hcloud SSH key names (HCLOUD_SSH_KEYS, comma
+	// separated) installed on newly created servers. May be empty.
+	sshKeyNames []string
+	// cloudInit is the raw cloud-init user data (HCLOUD_CLOUD_INIT) used to
+	// install and join newly created servers to the cluster. May be empty.
+	cloudInit string
+
+	clusterConfig    *clusterConfig
+	cachedServerType *serverTypeCache
+	rateLimiter      *rateLimiter
+	robotClient      *robotClient
+
+	// placementGroups tracks, per node group id, the chain of placement
+	// groups backing it: index 0 is the originally configured placement
+	// group, and any further entries are overflow groups created once
+	// earlier ones filled up to hetznerPlacementGroupMaxSize.
+	placementGroups map[string][]*hcloud.PlacementGroup
+
+	autoDiscoverySpecs []autoDiscoveryConfig
+	clusterUpdateMutex *sync.Mutex
+}
+
+// hetznerDefaultImage is the hcloud image newly created servers are booted
+// from when HCLOUD_IMAGE is not set.
+const hetznerDefaultImage = "ubuntu-22.04"
+
+func newManager() (*hetznerManager, error) {
+	token := os.Getenv("HCLOUD_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("HCLOUD_TOKEN is not set")
+	}
+
+	image := os.Getenv("HCLOUD_IMAGE")
+	if image == "" {
+		image = hetznerDefaultImage
+	}
+
+	var sshKeyNames []string
+	if keys := os.Getenv("HCLOUD_SSH_KEYS"); keys != "" {
+		for _, key := range strings.Split(keys, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				sshKeyNames = append(sshKeyNames, key)
+			}
+		}
+	}
+
+	limiter := newRateLimiter()
+
+	opts := withRateLimit(limiter, []hcloud.ClientOption{
+		hcloud.WithToken(token),
+		hcloud.WithApplication("cluster-autoscaler", "1.0"),
+	})
+	client := hcloud.NewClient(opts...)
+
+	manager := &hetznerManager{
+		client:           client,
+		nodeGroups:       make(map[string]*hetznerNodeGroup),
+		apiCallContext:   context.Background(),
+		image:            image,
+		sshKeyNames:      sshKeyNames,
+		cloudInit:        os.Getenv("HCLOUD_CLOUD_INIT"),
+		clusterConfig:    &clusterConfig{},
+		cachedServerType: newServerTypeCache(client),
+		rateLimiter:      limiter,
+		placementGroups:  make(map[string][]*hcloud.PlacementGroup),
+	}
+
+	return manager, nil
+}
+
+// serverTypeByName returns the cached server type matching name, the same
+// instanceType recorded on a hetznerNodeGroup.
+func (m *hetznerManager) serverTypeByName(name string) (*hcloud.ServerType, error) {
+	serverTypes, err := m.cachedServerType.getAllServerTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, serverType := range serverTypes {
+		if strings.EqualFold(serverType.Name, name) {
+			return serverType, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown server type %s", name)
+}
+
+// resolveSSHKeys looks up the hcloud SSH keys configured via HCLOUD_SSH_KEYS,
+// returning nil if none were configured.
+func (m *hetznerManager) resolveSSHKeys(ctx context.Context) ([]*hcloud.SSHKey, error) {
+	if len(m.sshKeyNames) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]*hcloud.SSHKey, 0, len(m.sshKeyNames))
+	for _, name := range m.sshKeyNames {
+		key, _, err := m.client.SSHKey.Get(m.apiCallContext, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve SSH key %s: %v", name, err)
+		}
+		if key == nil {
+			return nil, fmt.Errorf("SSH key %s not found", name)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// createServer provisions a single server for group, booting it from the
+// manager's configured image and cloud-init user data so it installs and
+// joins the cluster on its own, and places it into placementGroup when one
+// is given.
+func (m *hetznerManager) createServer(group *hetznerNodeGroup, serverType *hcloud.ServerType, placementGroup *hcloud.PlacementGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), serverCreateTimeoutDefault)
+	defer cancel()
+
+	image, _, err := m.client.Image.Get(ctx, m.image)
+	if err != nil {
+		return fmt.Errorf("failed to resolve image %s: %v", m.image, err)
+	}
+	if image == nil {
+		return fmt.Errorf("image %s not found", m.image)
+	}
+
+	location, _, err := m.client.Location.Get(ctx, group.region)
+	if err != nil {
+		return fmt.Errorf("failed to resolve location %s: %v", group.region, err)
+	}
+	if location == nil {
+		return fmt.Errorf("location %s not found", group.region)
+	}
+
+	sshKeys, err := m.resolveSSHKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%d", group.id, time.Now().UnixNano())
+	result, _, err := m.client.Server.Create(ctx, hcloud.ServerCreateOpts{
+		Name:           name,
+		ServerType:     serverType,
+		Image:          image,
+		Location:       location,
+		SSHKeys:        sshKeys,
+		UserData:       m.cloudInit,
+		Labels:         map[string]string{nodeGroupLabel: group.id},
+		PlacementGroup: placementGroup,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create server %s: %v", name, err)
+	}
+
+	klog.V(2).Infof("created server %s (id %d) for node group %s", name, result.Server.ID, group.id)
+	return nil
+}
+
+// allServers returns the servers that are members of the given node group,
+// as discovered through the nodeGroupLabel.
+func (m *hetznerManager) allServers(nodeGroupID string) ([]*hcloud.Server, error) {
+	listOpts := hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: fmt.Sprintf("%s=%s", nodeGroupLabel, nodeGroupID),
+		},
+	}
+
+	servers, err := m.client.Server.AllWithOpts(m.apiCallContext, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers for node group %s: %v", nodeGroupID, err)
+	}
+
+	return servers, nil
+}
+
+// serverForNode returns the hcloud server backing the given node, or nil if
+// the node's provider ID does not refer to a known hcloud server.
+func (m *hetznerManager) serverForNode(node *apiv1.Node) (*hcloud.Server, error) {
+	if node.Spec.ProviderID == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(node.Spec.ProviderID, providerIDPrefix) {
+		return nil, nil
+	}
+
+	serverID, err := strconv.ParseInt(strings.TrimPrefix(node.Spec.ProviderID, providerIDPrefix), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server id from provider id %s: %v", node.Spec.ProviderID, err)
+	}
+
+	server, _, err := m.client.Server.GetByID(m.apiCallContext, serverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server %d: %v", serverID, err)
+	}
+
+	return server, nil
+}
+
+// refreshAutoDiscoveredNodeGroups lists servers matching each configured
+// auto discovery label, groups them by the value of that spec's own label
+// key found on them, and adds or removes entries in m.nodeGroups
+// accordingly. A listing failure is returned to the caller: Refresh only
+// logs it, while BuildHetzner treats a failure of the initial discovery as
+// fatal.
+func (m *hetznerManager) refreshAutoDiscoveredNodeGroups() error {
+	discovered := make(map[string][]*hcloud.Server)
+
+	for _, spec := range m.autoDiscoverySpecs {
+		listOpts := hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{LabelSelector: spec.label},
+		}
+
+		servers, err := m.client.Server.AllWithOpts(m.apiCallContext, listOpts)
+		if err != nil {
+			return fmt.Errorf("failed to list servers for auto discovery spec `label=%s`: %v", spec.label, err)
+		}
+
+		for _, server := range servers {
+			groupID, ok := server.Labels[spec.label]
+			if !ok {
+				continue
+			}
+
+			discovered[groupID] = append(discovered[groupID], server)
+
+			if _, exists := m.nodeGroups[groupID]; !exists {
+				first := server
+				group := &hetznerNodeGroup{
+					manager:            m,
+					id:                 groupID,
+					minSize:            spec.minSize,
+					maxSize:            spec.maxSize,
+					instanceType:       strings.ToLower(first.ServerType.Name),
+					region:             strings.ToLower(first.Datacenter.Location.Name),
+					targetSize:         0,
+					clusterUpdateMutex: m.clusterUpdateMutex,
+					autoDiscovered:     true,
+					terminationPolicy:  terminationPolicyRandom,
+				}
+				group.backend = newHCloudBackend(group)
+				m.nodeGroups[groupID] = group
+				klog.V(2).Infof("auto discovered new node group %s", groupID)
+			}
+		}
+	}
+
+	for groupID, group := range m.nodeGroups {
+		if !group.autoDiscovered {
+			continue
+		}
+
+		servers, stillExists := discovered[groupID]
+		if !stillExists {
+			klog.V(2).Infof("removing auto discovered node group %s, no labeled servers remain", groupID)
+			delete(m.nodeGroups, groupID)
+			continue
+		}
+
+		group.targetSize = len(servers)
+	}
+
+	return nil
+}
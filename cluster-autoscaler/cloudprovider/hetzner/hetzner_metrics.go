@@ -0,0 +1,41 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	rateLimitExceededTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cluster_autoscaler",
+		Name:      "hetzner_rate_limit_exceeded_total",
+		Help:      "Number of times the Hetzner API responded with a rate limit exceeded error.",
+	})
+
+	rateLimitResetSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cluster_autoscaler",
+		Name:      "hetzner_rate_limit_reset_seconds",
+		Help:      "Seconds until the Hetzner API rate limit window is expected to reset, as of the last observed response.",
+	})
+)
+
+func init() {
+	legacyregistry.MustRegister(rateLimitExceededTotal)
+	legacyregistry.MustRegister(rateLimitResetSeconds)
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/config"
+	"k8s.io/autoscaler/cluster-autoscaler/simulator/framework"
+	"k8s.io/klog/v2"
+)
+
+var _ cloudprovider.NodeGroup = (*hetznerNodeGroup)(nil)
+
+// hetznerNodeGroup implements cloudprovider.NodeGroup interface. hetznerNodeGroup
+// contains configuration info and functions to control a set of nodes that
+// have the same capacity and set of labels.
+type hetznerNodeGroup struct {
+	manager      *hetznerManager
+	id           string
+	minSize      int
+	maxSize      int
+	targetSize   int
+	instanceType string
+	region       string
+
+	placementGroup *hcloud.PlacementGroup
+
+	// autoDiscovered is true if this node group was discovered dynamically
+	// via a `--node-group-auto-discovery=hcloud:...` spec rather than a
+	// static `--nodes=min:max:type:region:name` flag.
+	autoDiscovered bool
+
+	// backend drives the actual provisioning calls for this node group,
+	// either against HCloud virtual servers or a Robot dedicated server pool.
+	backend nodeGroupBackend
+
+	// terminationPolicy decides which servers are preferred deletion
+	// candidates when scaling down. Defaults to terminationPolicyRandom.
+	terminationPolicy terminationPolicy
+
+	// clusterUpdateMutex is shared by all node groups and the manager. It
+	// serializes target size updates and placement group bookkeeping; the
+	// server create and delete calls themselves are not made under it.
+	clusterUpdateMutex *sync.Mutex
+}
+
+// MaxSize returns maximum size of the node group.
+func (n *hetznerNodeGroup) MaxSize() int {
+	return n.maxSize
+}
+
+// MinSize returns minimum size of the node group.
+func (n *hetznerNodeGroup) MinSize() int {
+	return n.minSize
+}
+
+// TargetSize returns the current target size of the node group.
+func (n *hetznerNodeGroup) TargetSize() (int, error) {
+	return n.targetSize, nil
+}
+
+// resetTargetSize sets the target size to the number of servers currently
+// in the node group plus delta.
+func (n *hetznerNodeGroup) resetTargetSize(delta int) {
+	n.clusterUpdateMutex.Lock()
+	defer n.clusterUpdateMutex.Unlock()
+
+	servers, err := n.manager.allServers(n.id)
+	if err != nil {
+		klog.Errorf("failed to reset target size for node group %s: %v", n.id, err)
+		return
+	}
+
+	n.targetSize = len(servers) + delta
+}
+
+// IncreaseSize increases the size of the node group.
+func (n *hetznerNodeGroup) IncreaseSize(delta int) error {
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+
+	targetSize := n.targetSize + delta
+	if targetSize > n.MaxSize() {
+		return fmt.Errorf("size increase is too large, node group size %d target size %d max size %d", n.targetSize, targetSize, n.MaxSize())
+	}
+
+	return n.backend.IncreaseSize(delta)
+}
+
+// DeleteNodes deletes nodes from this node group.
+func (n *hetznerNodeGroup) DeleteNodes(nodes []*apiv1.Node) error {
+	return n.backend.DeleteNodes(nodes)
+}
+
+// DecreaseTargetSize decreases the target size of the node group.
+func (n *hetznerNodeGroup) DecreaseTargetSize(delta int) error {
+	if delta >= 0 {
+		return fmt.Errorf("size decrease size must be negative")
+	}
+
+	n.clusterUpdateMutex.Lock()
+	defer n.clusterUpdateMutex.Unlock()
+
+	n.targetSize += delta
+	return nil
+}
+
+// Id returns an unique identifier of the node group.
+func (n *hetznerNodeGroup) Id() string {
+	return n.id
+}
+
+// Debug returns a string containing all information regarding this node group.
+func (n *hetznerNodeGroup) Debug() string {
+	return fmt.Sprintf("%s (%d:%d)", n.Id(), n.MinSize(), n.MaxSize())
+}
+
+// Nodes returns a list of all nodes belonging to this node group.
+func (n *hetznerNodeGroup) Nodes() ([]cloudprovider.Instance, error) {
+	return n.backend.Nodes()
+}
+
+// TemplateNodeInfo returns a framework.NodeInfo structure of the requested node group.
+func (n *hetznerNodeGroup) TemplateNodeInfo() (*framework.NodeInfo, error) {
+	return nil, cloudprovider.ErrNotImplemented
+}
+
+// Exist checks if the node group really exists on the cloud provider side.
+func (n *hetznerNodeGroup) Exist() bool {
+	return true
+}
+
+// Create creates the node group on the cloud provider side.
+func (n *hetznerNodeGroup) Create() (cloudprovider.NodeGroup, error) {
+	return nil, cloudprovider.ErrAlreadyExist
+}
+
+// Delete deletes the node group on the cloud provider side.
+func (n *hetznerNodeGroup) Delete() error {
+	return cloudprovider.ErrNotImplemented
+}
+
+// Autoprovisioned returns true if the node group is autoprovisioned.
+func (n *hetznerNodeGroup) Autoprovisioned() bool {
+	return false
+}
+
+// GetOptions returns NodeGroupAutoscalingOptions that should be used for this particular
+// NodeGroup. Returning a nil will result in using default options.
+func (n *hetznerNodeGroup) GetOptions(defaults config.NodeGroupAutoscalingOptions) (*config.NodeGroupAutoscalingOptions, error) {
+	return nil, cloudprovider.ErrNotImplemented
+}
+
+func toInstance(server *hcloud.Server) cloudprovider.Instance {
+	return cloudprovider.Instance{
+		Id:     fmt.Sprintf("%s%d", providerIDPrefix, server.ID),
+		Status: &cloudprovider.InstanceStatus{State: cloudprovider.InstanceRunning},
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"k8s.io/klog/v2"
+)
+
+// hetznerPlacementGroupMaxSize is Hetzner's hard limit on the number of
+// servers that can share a single spread placement group.
+const hetznerPlacementGroupMaxSize = 10
+
+// placementGroupAllocation is a slice of a scale-up that should be placed
+// into a particular placement group.
+type placementGroupAllocation struct {
+	group *hcloud.PlacementGroup
+	count int
+}
+
+// ensurePlacementGroupCapacity plans where to put additionalServers more
+// members of node group nodeGroupID, filling the last placement group of its
+// chain and then creating (or reusing) deterministically-named overflow
+// placement groups - e.g. `<original-name>-2`, `<original-name>-3`, ... - as
+// each one fills up to hetznerPlacementGroupMaxSize. Free capacity in earlier
+// placement groups of the chain is not used. The returned
+// allocations sum to additionalServers. Returns nil if the node group has no
+// placement group configured, in which case the caller should provision
+// without a placement group.
+func (m *hetznerManager) ensurePlacementGroupCapacity(nodeGroupID string, additionalServers int) ([]placementGroupAllocation, error) {
+	// IncreaseSize runs concurrently across node groups, and this reads and
+	// overwrites m.placementGroups in place, so it must be serialized with
+	// every other caller mutating that map - the same mutex shared by every
+	// hetznerNodeGroup.clusterUpdateMutex.
+	m.clusterUpdateMutex.Lock()
+	defer m.clusterUpdateMutex.Unlock()
+
+	groups := m.placementGroups[nodeGroupID]
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	var allocations []placementGroupAllocation
+	current := groups[len(groups)-1]
+	remaining := additionalServers
+
+	count, err := m.serverCountInPlacementGroup(current)
+	if err != nil {
+		return nil, err
+	}
+	free := hetznerPlacementGroupMaxSize - count
+
+	// free tracks capacity of the in-memory `current` group as allocations
+	// are planned against it, rather than re-querying the API each
+	// iteration - otherwise, since no server has actually been created yet,
+	// a re-query would keep reporting the same stale count and we'd never
+	// roll over into an overflow group.
+	for remaining > 0 {
+		if free <= 0 {
+			overflow, err := m.createOverflowPlacementGroup(groups[0], len(groups)+1)
+			if err != nil {
+				return nil, err
+			}
+
+			groups = append(groups, overflow)
+			m.placementGroups[nodeGroupID] = groups
+			current = overflow
+			free = hetznerPlacementGroupMaxSize
+
+			klog.V(2).Infof("created overflow placement group %s for node group %s", overflow.Name, nodeGroupID)
+			continue
+		}
+
+		take := free
+		if take > remaining {
+			take = remaining
+		}
+
+		allocations = append(allocations, placementGroupAllocation{group: current, count: take})
+		remaining -= take
+		free -= take
+	}
+
+	return allocations, nil
+}
+
+// serverCountInPlacementGroup returns how many servers currently belong to
+// the given placement group.
+func (m *hetznerManager) serverCountInPlacementGroup(placementGroup *hcloud.PlacementGroup) (int, error) {
+	servers, err := m.client.Server.All(m.apiCallContext)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list servers to count placement group %s membership: %v", placementGroup.Name, err)
+	}
+
+	count := 0
+	for _, server := range servers {
+		if server.PlacementGroup != nil && server.PlacementGroup.ID == placementGroup.ID {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// createOverflowPlacementGroup creates (or fetches, if one already exists
+// from a previous run) the next overflow placement group in the chain
+// started by original, with the same spread policy.
+func (m *hetznerManager) createOverflowPlacementGroup(original *hcloud.PlacementGroup, index int) (*hcloud.PlacementGroup, error) {
+	name := fmt.Sprintf("%s-%d", original.Name, index)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	existing, _, err := m.client.PlacementGroup.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if overflow placement group `%s` exists: %v", name, err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	result, _, err := m.client.PlacementGroup.Create(ctx, hcloud.PlacementGroupCreateOpts{
+		Name: name,
+		Type: original.Type,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create overflow placement group `%s`: %v", name, err)
+	}
+
+	return result.PlacementGroup, nil
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// newTestManager returns a hetznerManager whose client talks to ts instead of
+// the real Hetzner API.
+func newTestManager(ts *httptest.Server) *hetznerManager {
+	client := hcloud.NewClient(hcloud.WithEndpoint(ts.URL), hcloud.WithToken("test"))
+	mutex := &sync.Mutex{}
+
+	return &hetznerManager{
+		client:             client,
+		apiCallContext:     context.Background(),
+		nodeGroups:         make(map[string]*hetznerNodeGroup),
+		placementGroups:    make(map[string][]*hcloud.PlacementGroup),
+		clusterUpdateMutex: mutex,
+	}
+}
+
+func placementGroupJSON(id int64, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"name": name,
+		"type": "spread",
+	}
+}
+
+func serverInPlacementGroupJSON(id, placementGroupID int64) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              id,
+		"placement_group": placementGroupJSON(placementGroupID, fmt.Sprintf("pg-%d", placementGroupID)),
+	}
+}
+
+func TestEnsurePlacementGroupCapacityNoGroupConfigured(t *testing.T) {
+	manager := newTestManager(httptest.NewServer(http.NotFoundHandler()))
+
+	allocations, err := manager.ensurePlacementGroupCapacity("unconfigured-group", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allocations != nil {
+		t.Fatalf("expected no allocations, got %+v", allocations)
+	}
+}
+
+func TestEnsurePlacementGroupCapacityWithinSingleGroup(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		servers := []map[string]interface{}{
+			serverInPlacementGroupJSON(1, 100),
+			serverInPlacementGroupJSON(2, 100),
+			serverInPlacementGroupJSON(3, 100),
+		}
+		writeServersPage(w, servers)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	manager := newTestManager(ts)
+	group := &hcloud.PlacementGroup{ID: 100, Name: "pg"}
+	manager.placementGroups["pool-a"] = []*hcloud.PlacementGroup{group}
+
+	allocations, err := manager.ensurePlacementGroupCapacity("pool-a", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(allocations) != 1 {
+		t.Fatalf("expected a single allocation, got %+v", allocations)
+	}
+	if allocations[0].group.ID != 100 || allocations[0].count != 2 {
+		t.Errorf("allocation = %+v, want {group: 100, count: 2}", allocations[0])
+	}
+	if len(manager.placementGroups["pool-a"]) != 1 {
+		t.Errorf("expected no overflow group to have been created, got %+v", manager.placementGroups["pool-a"])
+	}
+}
+
+func TestEnsurePlacementGroupCapacityOverflowsWhenFull(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		servers := make([]map[string]interface{}, hetznerPlacementGroupMaxSize)
+		for i := range servers {
+			servers[i] = serverInPlacementGroupJSON(int64(i+1), 100)
+		}
+		writeServersPage(w, servers)
+	})
+	mux.HandleFunc("/placement_groups", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, map[string]interface{}{
+				"placement_groups": []map[string]interface{}{},
+				"meta":             emptyPagination(),
+			})
+		case http.MethodPost:
+			writeJSON(w, map[string]interface{}{
+				"placement_group": placementGroupJSON(200, "pg-2"),
+			})
+		default:
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	manager := newTestManager(ts)
+	group := &hcloud.PlacementGroup{ID: 100, Name: "pg"}
+	manager.placementGroups["pool-a"] = []*hcloud.PlacementGroup{group}
+
+	allocations, err := manager.ensurePlacementGroupCapacity("pool-a", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(allocations) != 1 {
+		t.Fatalf("expected a single allocation into the overflow group, got %+v", allocations)
+	}
+	if allocations[0].group.ID != 200 || allocations[0].count != 3 {
+		t.Errorf("allocation = %+v, want {group: 200, count: 3}", allocations[0])
+	}
+	if len(manager.placementGroups["pool-a"]) != 2 {
+		t.Fatalf("expected the overflow group to be recorded, got %+v", manager.placementGroups["pool-a"])
+	}
+}
+
+func writeServersPage(w http.ResponseWriter, servers []map[string]interface{}) {
+	writeJSON(w, map[string]interface{}{
+		"servers": servers,
+		"meta":    emptyPagination(),
+	})
+}
+
+func emptyPagination() map[string]interface{} {
+	return map[string]interface{}{
+		"pagination": map[string]interface{}{
+			"page":           1,
+			"per_page":       25,
+			"last_page":      1,
+			"total_entries":  0,
+			"next_page":      nil,
+			"previous_page":  nil,
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/klog/v2"
+)
+
+var _ cloudprovider.PricingModel = (*hetznerPricingModel)(nil)
+
+// hoursPerMonth is the average number of hours Hetzner prorates a monthly
+// price over, for server types and other resources billed both hourly and
+// monthly.
+const hoursPerMonth = 24 * 30
+
+// hetznerPricingModel implements cloudprovider.PricingModel backed by the
+// manager's cached Hetzner server type price catalog.
+type hetznerPricingModel struct {
+	manager *hetznerManager
+}
+
+func newHetznerPricingModel(manager *hetznerManager) *hetznerPricingModel {
+	return &hetznerPricingModel{manager: manager}
+}
+
+// NodePrice returns a price of running the given node for a given period of
+// time, prorating the server type's monthly price - what Hetzner actually
+// bills at for anything running close to a full month - over the requested
+// window, and adding the node's IPv4 surcharge prorated the same way.
+func (p *hetznerPricingModel) NodePrice(node *apiv1.Node, startTime time.Time, endTime time.Time) (float64, error) {
+	instanceType, location, err := p.instanceTypeAndLocation(node)
+	if err != nil {
+		return 0, err
+	}
+
+	serverPrice, err := p.manager.cachedServerType.priceFor(instanceType, location)
+	if err != nil {
+		return 0, err
+	}
+
+	hours := endTime.Sub(startTime).Hours()
+	total := prorate(serverPrice, hours)
+
+	ipv4Price, err := p.manager.cachedServerType.ipv4SurchargePrice(location)
+	if err != nil {
+		klog.V(4).Infof("no IPv4 surcharge pricing for location %s, excluding it from node price: %v", location, err)
+	} else {
+		total += prorate(ipv4Price, hours)
+	}
+
+	return total, nil
+}
+
+// prorate returns the cost of price over the given number of hours,
+// preferring the monthly rate divided evenly across hoursPerMonth over the
+// flat hourly rate, since that's what Hetzner actually bills for anything
+// running close to a full month.
+func prorate(price serverPrice, hours float64) float64 {
+	if price.Monthly > 0 {
+		return price.Monthly / hoursPerMonth * hours
+	}
+	return price.Hourly * hours
+}
+
+// PodPrice returns a price of running the given pod for a given period of
+// time, estimated as its share - by CPU and memory request - of the
+// cheapest server type that could satisfy it.
+func (p *hetznerPricingModel) PodPrice(pod *apiv1.Pod, startTime time.Time, endTime time.Time) (float64, error) {
+	cpuRequest, memoryRequest := podResourceRequests(pod)
+
+	serverTypes, err := p.manager.cachedServerType.getAllServerTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	fit, err := cheapestFittingServerType(serverTypes, cpuRequest, memoryRequest)
+	if err != nil {
+		return 0, err
+	}
+
+	hourly, err := parseHourlyPrice(fit)
+	if err != nil {
+		return 0, err
+	}
+
+	share := resourceShare(cpuRequest, memoryRequest, fit)
+
+	return hourly * share * endTime.Sub(startTime).Hours(), nil
+}
+
+// instanceTypeAndLocation returns the hetzner server type and location that
+// back the given node: those of its hcloud server when one is found, else
+// the ones recorded on its node group.
+func (p *hetznerPricingModel) instanceTypeAndLocation(node *apiv1.Node) (string, string, error) {
+	server, err := p.manager.serverForNode(node)
+	if err != nil {
+		return "", "", err
+	}
+	if server != nil {
+		return server.ServerType.Name, server.Datacenter.Location.Name, nil
+	}
+
+	groupID, ok := node.Labels[nodeGroupLabel]
+	if !ok {
+		return "", "", fmt.Errorf("node %s has no %s label and no backing server", node.Name, nodeGroupLabel)
+	}
+
+	group, ok := p.manager.nodeGroups[groupID]
+	if !ok {
+		return "", "", fmt.Errorf("unknown node group %s for node %s", groupID, node.Name)
+	}
+
+	return group.instanceType, group.region, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// podResourceRequests sums up the CPU (in cores) and memory (in bytes)
+// requests across all containers of the pod.
+func podResourceRequests(pod *apiv1.Pod) (cpu float64, memory float64) {
+	for _, container := range pod.Spec.Containers {
+		cpu += float64(container.Resources.Requests.Cpu().MilliValue()) / 1000
+		memory += float64(container.Resources.Requests.Memory().Value())
+	}
+	return cpu, memory
+}
+
+// cheapestFittingServerType returns the server type with the lowest hourly
+// price (as given by parseHourlyPrice) among those with enough cores and
+// memory for the requested cpu (cores) and memory (bytes).
+func cheapestFittingServerType(serverTypes []*hcloud.ServerType, cpu float64, memory float64) (*hcloud.ServerType, error) {
+	var best *hcloud.ServerType
+	var bestPrice float64
+
+	for _, serverType := range serverTypes {
+		if float64(serverType.Cores) < cpu {
+			continue
+		}
+		if float64(serverType.Memory)*1024*1024*1024 < memory {
+			continue
+		}
+		if len(serverType.Pricings) == 0 {
+			continue
+		}
+
+		price, err := parseHourlyPrice(serverType)
+		if err != nil {
+			continue
+		}
+
+		if best == nil || price < bestPrice {
+			best = serverType
+			bestPrice = price
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no server type has enough resources for %.2f cores and %.0f bytes of memory", cpu, memory)
+	}
+
+	return best, nil
+}
+
+// resourceShare estimates a pod's share of a server type's capacity as the
+// larger of its CPU or memory request share, which is how much of the node
+// the pod's request would effectively reserve.
+func resourceShare(cpu float64, memory float64, serverType *hcloud.ServerType) float64 {
+	cpuShare := cpu / float64(serverType.Cores)
+	memShare := memory / (float64(serverType.Memory) * 1024 * 1024 * 1024)
+
+	if cpuShare > memShare {
+		return cpuShare
+	}
+	return memShare
+}
+
+// parseHourlyPrice returns the cheapest per-location hourly net price listed
+// for the server type.
+func parseHourlyPrice(serverType *hcloud.ServerType) (float64, error) {
+	if len(serverType.Pricings) == 0 {
+		return 0, fmt.Errorf("server type %s has no pricing information", serverType.Name)
+	}
+
+	var cheapest float64
+	found := false
+	for _, pricing := range serverType.Pricings {
+		value, err := strconv.ParseFloat(pricing.Hourly.Net, 64)
+		if err != nil {
+			continue
+		}
+		if !found || value < cheapest {
+			cheapest = value
+			found = true
+		}
+	}
+
+	if !found {
+		return 0, fmt.Errorf("server type %s has no parsable pricing information", serverType.Name)
+	}
+
+	return cheapest, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func serverTypeWithPrice(name string, cores int, memoryGB float64, hourlyNet string) *hcloud.ServerType {
+	return &hcloud.ServerType{
+		Name:   name,
+		Cores:  cores,
+		Memory: float32(memoryGB),
+		Pricings: []hcloud.ServerTypeLocationPricing{
+			{Hourly: hcloud.Price{Net: hourlyNet}},
+		},
+	}
+}
+
+func TestParseHourlyPrice(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverType *hcloud.ServerType
+		want       float64
+		wantErr    bool
+	}{
+		{
+			name:       "single pricing",
+			serverType: serverTypeWithPrice("cx22", 2, 4, "0.0060"),
+			want:       0.0060,
+		},
+		{
+			name: "cheapest of several locations",
+			serverType: &hcloud.ServerType{
+				Name: "cx22",
+				Pricings: []hcloud.ServerTypeLocationPricing{
+					{Hourly: hcloud.Price{Net: "0.0090"}},
+					{Hourly: hcloud.Price{Net: "0.0060"}},
+				},
+			},
+			want: 0.0060,
+		},
+		{
+			name:       "no pricing information",
+			serverType: &hcloud.ServerType{Name: "cx22"},
+			wantErr:    true,
+		},
+		{
+			name: "unparsable pricing",
+			serverType: &hcloud.ServerType{
+				Name:     "cx22",
+				Pricings: []hcloud.ServerTypeLocationPricing{{Hourly: hcloud.Price{Net: "not-a-number"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHourlyPrice(tt.serverType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseHourlyPrice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheapestFittingServerType(t *testing.T) {
+	small := serverTypeWithPrice("cx22", 2, 4, "0.0060")
+	medium := serverTypeWithPrice("cx32", 4, 8, "0.0120")
+	large := serverTypeWithPrice("cx42", 8, 16, "0.0240")
+	serverTypes := []*hcloud.ServerType{large, small, medium}
+
+	tests := []struct {
+		name    string
+		cpu     float64
+		memory  float64
+		want    string
+		wantErr bool
+	}{
+		{name: "fits smallest", cpu: 1, memory: 2 * 1024 * 1024 * 1024, want: "cx22"},
+		{name: "fits medium", cpu: 3, memory: 6 * 1024 * 1024 * 1024, want: "cx32"},
+		{name: "needs largest", cpu: 5, memory: 10 * 1024 * 1024 * 1024, want: "cx42"},
+		{name: "nothing fits", cpu: 100, memory: 1024 * 1024 * 1024 * 1024, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cheapestFittingServerType(serverTypes, tt.cpu, tt.memory)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("cheapestFittingServerType() = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceShare(t *testing.T) {
+	serverType := &hcloud.ServerType{Cores: 4, Memory: 8}
+
+	tests := []struct {
+		name   string
+		cpu    float64
+		memory float64
+		want   float64
+	}{
+		{name: "cpu dominates", cpu: 2, memory: 1 * 1024 * 1024 * 1024, want: 0.5},
+		{name: "memory dominates", cpu: 0.1, memory: 4 * 1024 * 1024 * 1024, want: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resourceShare(tt.cpu, tt.memory, serverType)
+			if got != tt.want {
+				t.Errorf("resourceShare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"k8s.io/klog/v2"
+)
+
+const (
+	rateLimitBackoffBase = 1 * time.Second
+	rateLimitBackoffMax  = 30 * time.Second
+	rateLimitMaxRetries  = 5
+)
+
+// ErrRateLimited is returned whenever an API call was short-circuited
+// because the Hetzner API rate limit is currently exceeded. Callers should
+// treat it as a transient, retryable error rather than a fatal one.
+type ErrRateLimited struct {
+	// RetryAfter is how long the caller should wait before trying again.
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	return "hetzner API rate limit exceeded, retry after " + e.RetryAfter.String()
+}
+
+// RateLimitStatus reports the current, observed state of the Hetzner API
+// rate limit as seen by the most recent response.
+type RateLimitStatus struct {
+	// Exceeded is true while calls are being short-circuited because the
+	// rate limit was hit and has not yet reset.
+	Exceeded bool
+	// ResetAt is the time at which the rate limit window resets.
+	ResetAt time.Time
+}
+
+// rateLimiter tracks the Hetzner API rate limit state across all requests
+// issued by a hetznerManager and short-circuits non-idempotent calls once
+// the limit has been exceeded, until the reset time has passed.
+type rateLimiter struct {
+	mutex   sync.Mutex
+	resetAt time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{}
+}
+
+// status returns the current rate limit status.
+func (r *rateLimiter) status() RateLimitStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return RateLimitStatus{
+		Exceeded: time.Now().Before(r.resetAt),
+		ResetAt:  r.resetAt,
+	}
+}
+
+// blocked reports whether calls should currently be short-circuited, and if
+// so for how much longer.
+func (r *rateLimiter) blocked() (time.Duration, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if wait := time.Until(r.resetAt); wait > 0 {
+		return wait, true
+	}
+	return 0, false
+}
+
+// recordExceeded records that the rate limit was hit and will reset at
+// resetAt. rateLimitExceededTotal counts every observed 429, not just the
+// ones that push resetAt further out, so a burst of them within the same
+// window is still reflected in the metric.
+func (r *rateLimiter) recordExceeded(resetAt time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rateLimitExceededTotal.Inc()
+
+	if resetAt.After(r.resetAt) {
+		r.resetAt = resetAt
+	}
+}
+
+// rateLimitTransport is an http.RoundTripper middleware that observes the
+// `RateLimit-Reset` header on every hcloud API response, records it on the
+// shared rateLimiter, and retries idempotent (GET) requests with capped
+// exponential backoff and jitter when a 429 is returned. Non-idempotent
+// requests are not retried transparently; instead the caller is expected to
+// check the rate limiter status before issuing them.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		resp, err = t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		rateLimitGaugeFromHeader(resp.Header)
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		resetAt := parseRateLimitReset(resp.Header)
+		t.limiter.recordExceeded(resetAt)
+
+		if req.Method != http.MethodGet || attempt >= rateLimitMaxRetries {
+			return resp, nil
+		}
+
+		wait := backoffWithJitter(attempt)
+		klog.V(3).Infof("hetzner: rate limited on %s %s, retrying in %s (attempt %d/%d)", req.Method, req.URL.Path, wait, attempt+1, rateLimitMaxRetries)
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return resp, req.Context().Err()
+		}
+	}
+}
+
+func backoffWithJitter(attempt int) time.Duration {
+	backoff := rateLimitBackoffBase << attempt
+	if backoff > rateLimitBackoffMax || backoff <= 0 {
+		backoff = rateLimitBackoffMax
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
+	return backoff/2 + jitter
+}
+
+// parseRateLimitReset reads the `RateLimit-Reset` header, which Hetzner
+// documents as a unix timestamp, and falls back to a short default when it
+// is absent or malformed.
+func parseRateLimitReset(header http.Header) time.Time {
+	raw := header.Get("RateLimit-Reset")
+	if raw == "" {
+		return time.Now().Add(rateLimitBackoffMax)
+	}
+
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Now().Add(rateLimitBackoffMax)
+	}
+
+	return time.Unix(seconds, 0)
+}
+
+func rateLimitGaugeFromHeader(header http.Header) {
+	resetAt := parseRateLimitReset(header)
+	remaining := time.Until(resetAt).Seconds()
+	if remaining > 0 {
+		rateLimitResetSeconds.Set(remaining)
+	}
+}
+
+// withRateLimit wraps the given hcloud client options with the rate-limit
+// aware transport.
+func withRateLimit(limiter *rateLimiter, opts []hcloud.ClientOption) []hcloud.ClientOption {
+	return append(opts, hcloud.WithHTTPClient(&http.Client{
+		Transport: &rateLimitTransport{base: http.DefaultTransport, limiter: limiter},
+	}))
+}
+
+// checkRateLimit returns ErrRateLimited if non-idempotent calls are
+// currently short-circuited due to an earlier rate limit response.
+func checkRateLimit(limiter *rateLimiter) error {
+	if wait, blocked := limiter.blocked(); blocked {
+		return ErrRateLimited{RetryAfter: wait}
+	}
+	return nil
+}
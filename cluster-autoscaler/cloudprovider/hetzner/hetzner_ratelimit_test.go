@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBackoffWithJitter(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		wait := backoffWithJitter(attempt)
+
+		if wait <= 0 {
+			t.Fatalf("attempt %d: expected a positive backoff, got %s", attempt, wait)
+		}
+		if wait > rateLimitBackoffMax {
+			t.Fatalf("attempt %d: backoff %s exceeds max %s", attempt, wait, rateLimitBackoffMax)
+		}
+	}
+}
+
+func TestParseRateLimitReset(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header string
+		want   func(time.Time) bool
+	}{
+		{
+			name:   "valid unix timestamp",
+			header: strconv.FormatInt(now.Add(time.Minute).Unix(), 10),
+			want: func(got time.Time) bool {
+				return got.Unix() == now.Add(time.Minute).Unix()
+			},
+		},
+		{
+			name:   "missing header falls back to default backoff",
+			header: "",
+			want: func(got time.Time) bool {
+				return !got.Before(now) && got.Before(now.Add(rateLimitBackoffMax+time.Second))
+			},
+		},
+		{
+			name:   "malformed header falls back to default backoff",
+			header: "not-a-timestamp",
+			want: func(got time.Time) bool {
+				return !got.Before(now) && got.Before(now.Add(rateLimitBackoffMax+time.Second))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("RateLimit-Reset", tt.header)
+			}
+
+			got := parseRateLimitReset(header)
+			if !tt.want(got) {
+				t.Errorf("parseRateLimitReset(%q) = %s, did not satisfy expectation", tt.header, got)
+			}
+		})
+	}
+}
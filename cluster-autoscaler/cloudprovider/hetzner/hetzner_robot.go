@@ -0,0 +1,404 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/klog/v2"
+)
+
+const (
+	robotProviderIDPrefix = "hrobot://"
+	robotAPIBaseURL       = "https://robot-ws.your-server.de"
+
+	// rescueDialTimeout bounds each individual SSH connection attempt while
+	// waiting for a server to come up in rescue mode.
+	rescueDialTimeout = 10 * time.Second
+	// rescueDialRetryInterval is how long to wait between SSH connection
+	// attempts while a server is rebooting into rescue mode.
+	rescueDialRetryInterval = 10 * time.Second
+)
+
+// robotClient is a minimal client for the subset of the Hetzner Robot
+// webservice API needed to reserve and (re)install pool servers. Unlike
+// HCloud, Robot has no official Go SDK, so this talks to the REST API
+// directly using HTTP basic auth.
+type robotClient struct {
+	httpClient *http.Client
+	baseURL    string
+	user       string
+	password   string
+
+	// sshKeyFingerprint, if set (ROBOT_SSH_KEY_FINGERPRINT), is an existing
+	// Robot SSH key fingerprint installed into the rescue system; the Robot
+	// API then generates no rescue root password.
+	sshKeyFingerprint string
+	// installScript, if set (ROBOT_INSTALL_SCRIPT), is run over SSH against
+	// the rescue system to install and join a reserved server to the
+	// cluster. Required for IncreaseSize to actually provision a node.
+	installScript string
+}
+
+func newRobotClient() (*robotClient, error) {
+	user := os.Getenv("ROBOT_USER")
+	password := os.Getenv("ROBOT_PASSWORD")
+	if user == "" || password == "" {
+		return nil, fmt.Errorf("ROBOT_USER and ROBOT_PASSWORD must be set to use the robot backend")
+	}
+
+	return &robotClient{
+		httpClient:        http.DefaultClient,
+		baseURL:           robotAPIBaseURL,
+		user:              user,
+		password:          password,
+		sshKeyFingerprint: os.Getenv("ROBOT_SSH_KEY_FINGERPRINT"),
+		installScript:     os.Getenv("ROBOT_INSTALL_SCRIPT"),
+	}, nil
+}
+
+func (c *robotClient) do(req *http.Request) ([]byte, error) {
+	req.SetBasicAuth(c.user, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read robot API response from %s: %v", req.URL.Path, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return body, fmt.Errorf("robot API request to %s failed with status %s", req.URL.Path, resp.Status)
+	}
+
+	return body, nil
+}
+
+func (c *robotClient) get(path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.do(req)
+}
+
+func (c *robotClient) post(path string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.do(req)
+}
+
+// rescueActivationResponse is the subset of the `POST /boot/{id}/rescue`
+// response body this client cares about. The `password` field is only
+// populated when the request did not supply an authorized key. `host_key`
+// lists the rescue system's own SSH host public keys, freshly generated for
+// this activation, which lets dialRescue verify it is really talking to the
+// rescue system instead of trusting the connection blindly.
+type rescueActivationResponse struct {
+	Rescue struct {
+		Password string   `json:"password"`
+		HostKey  []string `json:"host_key"`
+	} `json:"rescue"`
+}
+
+// activateRescue enables rescue mode on the server so that the next reset
+// boots it into a minimal Linux environment that can run the configured
+// install script instead of the currently installed OS. It returns the
+// generated rescue root password (empty when sshKeyFingerprint was supplied
+// instead) and the rescue system's SSH host public keys.
+func (c *robotClient) activateRescue(serverNumber int) (string, []string, error) {
+	form := url.Values{"os": {"linux"}}
+	if c.sshKeyFingerprint != "" {
+		form.Add("authorized_keys[]", c.sshKeyFingerprint)
+	}
+
+	body, err := c.post(fmt.Sprintf("/boot/%d/rescue", serverNumber), form)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var parsed rescueActivationResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", nil, fmt.Errorf("failed to parse rescue activation response for server %d: %v", serverNumber, err)
+	}
+
+	return parsed.Rescue.Password, parsed.Rescue.HostKey, nil
+}
+
+// reset power-cycles the server so it boots into the mode activated by
+// activateRescue.
+func (c *robotClient) reset(serverNumber int) error {
+	_, err := c.post(fmt.Sprintf("/reset/%d", serverNumber), url.Values{"type": {"hw"}})
+	return err
+}
+
+// serverResponse is the subset of the `GET /server/{id}` response body this
+// client cares about.
+type serverResponse struct {
+	Server struct {
+		ServerIP string `json:"server_ip"`
+	} `json:"server"`
+}
+
+// serverIP looks up the main IPv4 address of a Robot server, used to reach
+// its rescue system over SSH.
+func (c *robotClient) serverIP(serverNumber int) (string, error) {
+	body, err := c.get(fmt.Sprintf("/server/%d", serverNumber))
+	if err != nil {
+		return "", err
+	}
+
+	var parsed serverResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("failed to parse server details response for server %d: %v", serverNumber, err)
+	}
+	if parsed.Server.ServerIP == "" {
+		return "", fmt.Errorf("server %d has no IPv4 address on file", serverNumber)
+	}
+
+	return parsed.Server.ServerIP, nil
+}
+
+// install connects to the server's rescue system over SSH and runs the
+// configured install script, which is expected to partition the disk,
+// install an OS and kubelet, and join the node to the cluster. The connection
+// authenticates with rescuePassword only; no key-based authentication is
+// attempted, also when sshKeyFingerprint is configured and rescuePassword is
+// therefore empty. hostKeys, when non-empty, pins the rescue system's SSH host keys as
+// reported by the Robot API activation response.
+func (c *robotClient) install(serverNumber int, rescuePassword string, hostKeys []string) error {
+	if c.installScript == "" {
+		return fmt.Errorf("ROBOT_INSTALL_SCRIPT is not set, cannot install robot server %d", serverNumber)
+	}
+
+	ip, err := c.serverIP(serverNumber)
+	if err != nil {
+		return fmt.Errorf("failed to resolve IP for robot server %d: %v", serverNumber, err)
+	}
+
+	client, err := c.dialRescue(ip, rescuePassword, hostKeys)
+	if err != nil {
+		return fmt.Errorf("failed to connect to rescue system on robot server %d: %v", serverNumber, err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open SSH session to robot server %d: %v", serverNumber, err)
+	}
+	defer session.Close()
+
+	if err := session.Run(c.installScript); err != nil {
+		return fmt.Errorf("install script failed on robot server %d: %v", serverNumber, err)
+	}
+
+	return nil
+}
+
+// dialRescue connects to a server's rescue-mode SSH daemon, retrying at
+// rescueDialRetryInterval until it comes up or serverRegisterTimeout elapses:
+// a fresh reset() takes the server tens of seconds to minutes to reboot and
+// start sshd, so the first few attempts are expected to fail.
+func (c *robotClient) dialRescue(ip, rescuePassword string, hostKeys []string) (*ssh.Client, error) {
+	hostKeyCallback, err := rescueHostKeyCallback(hostKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rescue host keys for %s: %v", ip, err)
+	}
+
+	config := &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.Password(rescuePassword)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         rescueDialTimeout,
+	}
+	addr := net.JoinHostPort(ip, "22")
+
+	deadline := time.Now().Add(serverRegisterTimeout)
+	var lastErr error
+	for {
+		client, err := ssh.Dial("tcp", addr, config)
+		if err == nil {
+			return client, nil
+		}
+		lastErr = err
+
+		if time.Now().Add(rescueDialRetryInterval).After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for rescue system at %s to come up: %v", addr, lastErr)
+		}
+		time.Sleep(rescueDialRetryInterval)
+	}
+}
+
+// rescueHostKeyCallback builds an ssh.HostKeyCallback that accepts only the
+// given rescue system host keys, as reported by the Robot API activation
+// response. If none were reported, it falls back to accepting any host key:
+// the rescue system's keys are freshly generated per activation and have no
+// prior TOFU record to compare against, so there is nothing else to pin
+// against in that case.
+func rescueHostKeyCallback(hostKeys []string) (ssh.HostKeyCallback, error) {
+	if len(hostKeys) == 0 {
+		klog.Warningf("rescue activation response did not include host keys, connecting without host key verification")
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	allowed := make([][]byte, 0, len(hostKeys))
+	for _, raw := range hostKeys {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("unparsable host key %q: %v", raw, err)
+		}
+		allowed = append(allowed, key.Marshal())
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		presented := key.Marshal()
+		for _, candidate := range allowed {
+			if bytes.Equal(candidate, presented) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host key for %s does not match any key reported by the rescue activation", hostname)
+	}, nil
+}
+
+// robotBackend implements nodeGroupBackend against a pre-provisioned pool of
+// Hetzner Robot dedicated servers. IncreaseSize reserves free servers from
+// the pool and reinstalls them via rescue mode; DeleteNodes returns their
+// servers to the pool for reuse rather than actually destroying them, since
+// Robot servers are rented longer-term hardware rather than billed by the
+// hour.
+type robotBackend struct {
+	group  *hetznerNodeGroup
+	client *robotClient
+
+	mutex sync.Mutex
+	free  []int
+	inUse map[int]bool
+}
+
+func newRobotBackend(group *hetznerNodeGroup, client *robotClient, pool []int) *robotBackend {
+	return &robotBackend{
+		group:  group,
+		client: client,
+		free:   pool,
+		inUse:  make(map[int]bool),
+	}
+}
+
+func (b *robotBackend) IncreaseSize(delta int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if delta > len(b.free) {
+		return fmt.Errorf("not enough free servers in robot pool for node group %s: need %d, have %d", b.group.id, delta, len(b.free))
+	}
+
+	for i := 0; i < delta; i++ {
+		serverNumber := b.free[0]
+		b.free = b.free[1:]
+
+		password, hostKeys, err := b.client.activateRescue(serverNumber)
+		if err != nil {
+			b.free = append(b.free, serverNumber)
+			return fmt.Errorf("failed to activate rescue mode on robot server %d: %v", serverNumber, err)
+		}
+		if err := b.client.reset(serverNumber); err != nil {
+			b.free = append(b.free, serverNumber)
+			return fmt.Errorf("failed to reset robot server %d: %v", serverNumber, err)
+		}
+
+		// Only mark the server in use once it has actually been installed;
+		// a rescue boot and reset alone leaves it without an OS or kubelet.
+		if err := b.client.install(serverNumber, password, hostKeys); err != nil {
+			b.free = append(b.free, serverNumber)
+			return fmt.Errorf("failed to install robot server %d: %v", serverNumber, err)
+		}
+
+		b.inUse[serverNumber] = true
+		klog.V(2).Infof("reserved robot server %d for node group %s", serverNumber, b.group.id)
+	}
+
+	return nil
+}
+
+func (b *robotBackend) DeleteNodes(nodes []*apiv1.Node) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, node := range nodes {
+		serverNumber, err := robotServerNumberFromProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return err
+		}
+
+		if !b.inUse[serverNumber] {
+			continue
+		}
+
+		delete(b.inUse, serverNumber)
+		b.free = append(b.free, serverNumber)
+		klog.V(2).Infof("returned robot server %d to pool for node group %s", serverNumber, b.group.id)
+	}
+
+	return nil
+}
+
+func (b *robotBackend) Nodes() ([]cloudprovider.Instance, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	instances := make([]cloudprovider.Instance, 0, len(b.inUse))
+	for serverNumber := range b.inUse {
+		instances = append(instances, cloudprovider.Instance{
+			Id:     fmt.Sprintf("%s%d", robotProviderIDPrefix, serverNumber),
+			Status: &cloudprovider.InstanceStatus{State: cloudprovider.InstanceRunning},
+		})
+	}
+
+	return instances, nil
+}
+
+func robotServerNumberFromProviderID(providerID string) (int, error) {
+	if !strings.HasPrefix(providerID, robotProviderIDPrefix) {
+		return 0, fmt.Errorf("provider id %s is not a robot server", providerID)
+	}
+
+	return strconv.Atoi(strings.TrimPrefix(providerID, robotProviderIDPrefix))
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func newTestRobotClient(ts *httptest.Server) *robotClient {
+	return &robotClient{
+		httpClient: ts.Client(),
+		baseURL:    ts.URL,
+		user:       "user",
+		password:   "pass",
+	}
+}
+
+func TestActivateRescue(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boot/123/rescue", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rescue":{"password":"secret","host_key":["ssh-ed25519 AAAA fake"]}}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := newTestRobotClient(ts)
+	password, hostKeys, err := client.activateRescue(123)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if password != "secret" {
+		t.Errorf("password = %q, want %q", password, "secret")
+	}
+	if len(hostKeys) != 1 || hostKeys[0] != "ssh-ed25519 AAAA fake" {
+		t.Errorf("hostKeys = %v, want a single matching entry", hostKeys)
+	}
+}
+
+func TestServerIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "has ip", body: `{"server":{"server_ip":"1.2.3.4"}}`, want: "1.2.3.4"},
+		{name: "no ip on file", body: `{"server":{}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/server/123", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			})
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			got, err := newTestRobotClient(ts).serverIP(123)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("serverIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func generateTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate test key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert test key: %v", err)
+	}
+	return sshPub
+}
+
+func TestRescueHostKeyCallback(t *testing.T) {
+	expected := generateTestHostKey(t)
+	other := generateTestHostKey(t)
+	line := string(ssh.MarshalAuthorizedKey(expected))
+
+	callback, err := rescueHostKeyCallback([]string{line})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := callback("host:22", nil, expected); err != nil {
+		t.Errorf("expected the reported host key to be accepted, got: %v", err)
+	}
+	if err := callback("host:22", nil, other); err == nil {
+		t.Error("expected a key not in the rescue activation response to be rejected")
+	}
+}
+
+func TestRescueHostKeyCallbackFallsBackWhenNoneReported(t *testing.T) {
+	callback, err := rescueHostKeyCallback(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := callback("host:22", nil, generateTestHostKey(t)); err != nil {
+		t.Errorf("expected any host key to be accepted when none were reported, got: %v", err)
+	}
+}
+
+func TestRescueHostKeyCallbackUnparsableKey(t *testing.T) {
+	if _, err := rescueHostKeyCallback([]string{"not-a-valid-key"}); err == nil {
+		t.Fatal("expected an error for an unparsable host key")
+	}
+}
+
+func TestRobotServerNumberFromProviderID(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		want       int
+		wantErr    bool
+	}{
+		{name: "valid", providerID: "hrobot://123", want: 123},
+		{name: "wrong prefix", providerID: "hcloud://123", wantErr: true},
+		{name: "not a number", providerID: "hrobot://abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := robotServerNumberFromProviderID(tt.providerID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("robotServerNumberFromProviderID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotBackendDeleteNodesReturnsServersToPool(t *testing.T) {
+	backend := &robotBackend{
+		group: &hetznerNodeGroup{id: "pool-a"},
+		free:  []int{101},
+		inUse: map[int]bool{102: true},
+	}
+
+	node := &apiv1.Node{}
+	node.Spec.ProviderID = "hrobot://102"
+
+	if err := backend.DeleteNodes([]*apiv1.Node{node}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if backend.inUse[102] {
+		t.Error("expected server 102 to no longer be in use")
+	}
+
+	found := false
+	for _, serverNumber := range backend.free {
+		if serverNumber == 102 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected server 102 to be returned to the free pool, got %+v", backend.free)
+	}
+}
+
+func TestRobotBackendIncreaseSizeRejectsWhenPoolExhausted(t *testing.T) {
+	backend := &robotBackend{
+		group: &hetznerNodeGroup{id: "pool-a"},
+		free:  []int{101},
+		inUse: map[int]bool{},
+	}
+
+	if err := backend.IncreaseSize(2); err == nil {
+		t.Fatal("expected an error when requesting more servers than are free in the pool")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// terminationPolicy decides which servers in a node group are preferred
+// candidates for deletion when CA scales a group down.
+type terminationPolicy string
+
+const (
+	terminationPolicyOldest        terminationPolicy = "oldest"
+	terminationPolicyNewest        terminationPolicy = "newest"
+	terminationPolicyLeastUtilized terminationPolicy = "least-utilized"
+	terminationPolicyRandom        terminationPolicy = "random"
+)
+
+// parseTerminationPolicy parses the `terminationPolicy` new-format config
+// value, defaulting to terminationPolicyRandom for an empty or unrecognized
+// value so existing configs keep their current (API order) behavior.
+func parseTerminationPolicy(value string) terminationPolicy {
+	switch terminationPolicy(strings.ToLower(value)) {
+	case terminationPolicyOldest, terminationPolicyNewest, terminationPolicyLeastUtilized, terminationPolicyRandom:
+		return terminationPolicy(strings.ToLower(value))
+	default:
+		return terminationPolicyRandom
+	}
+}
+
+// sortServersForTermination orders servers in place so that the preferred
+// deletion candidate under policy sorts first.
+func sortServersForTermination(servers []*hcloud.Server, policy terminationPolicy) {
+	switch policy {
+	case terminationPolicyOldest:
+		sort.Slice(servers, func(i, j int) bool {
+			return servers[i].Created.Before(servers[j].Created)
+		})
+	case terminationPolicyNewest:
+		sort.Slice(servers, func(i, j int) bool {
+			return servers[i].Created.After(servers[j].Created)
+		})
+	case terminationPolicyLeastUtilized:
+		sort.Slice(servers, func(i, j int) bool {
+			return utilizationPercent(servers[i]) < utilizationPercent(servers[j])
+		})
+	default:
+		// terminationPolicyRandom: keep whatever order the API returned them in.
+	}
+}
+
+// utilizationPercent reads a best-effort utilization hint, in percent, from
+// the `hcloud/utilization` label that an external metrics-reporting agent
+// may set on the server. Servers without the label sort last, i.e. are
+// treated as fully utilized and so least preferred for removal.
+func utilizationPercent(server *hcloud.Server) int {
+	value, ok := server.Labels["hcloud/utilization"]
+	if !ok {
+		return 100
+	}
+
+	percent, err := strconv.Atoi(value)
+	if err != nil {
+		return 100
+	}
+
+	return percent
+}
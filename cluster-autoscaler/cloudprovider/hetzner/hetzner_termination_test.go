@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestParseTerminationPolicy(t *testing.T) {
+	tests := []struct {
+		value string
+		want  terminationPolicy
+	}{
+		{value: "oldest", want: terminationPolicyOldest},
+		{value: "newest", want: terminationPolicyNewest},
+		{value: "least-utilized", want: terminationPolicyLeastUtilized},
+		{value: "random", want: terminationPolicyRandom},
+		{value: "RANDOM", want: terminationPolicyRandom},
+		{value: "", want: terminationPolicyRandom},
+		{value: "bogus", want: terminationPolicyRandom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseTerminationPolicy(tt.value); got != tt.want {
+				t.Errorf("parseTerminationPolicy(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortServersForTermination(t *testing.T) {
+	now := time.Now()
+	oldServer := &hcloud.Server{ID: 1, Created: now.Add(-2 * time.Hour)}
+	newServer := &hcloud.Server{ID: 2, Created: now}
+	idleServer := &hcloud.Server{ID: 3, Created: now.Add(-time.Hour), Labels: map[string]string{"hcloud/utilization": "10"}}
+	busyServer := &hcloud.Server{ID: 4, Created: now.Add(-time.Hour), Labels: map[string]string{"hcloud/utilization": "90"}}
+
+	tests := []struct {
+		name    string
+		policy  terminationPolicy
+		servers []*hcloud.Server
+		wantIDs []int64
+	}{
+		{
+			name:    "oldest first",
+			policy:  terminationPolicyOldest,
+			servers: []*hcloud.Server{newServer, oldServer},
+			wantIDs: []int64{1, 2},
+		},
+		{
+			name:    "newest first",
+			policy:  terminationPolicyNewest,
+			servers: []*hcloud.Server{oldServer, newServer},
+			wantIDs: []int64{2, 1},
+		},
+		{
+			name:    "least utilized first",
+			policy:  terminationPolicyLeastUtilized,
+			servers: []*hcloud.Server{busyServer, idleServer},
+			wantIDs: []int64{3, 4},
+		},
+		{
+			name:    "random leaves order untouched",
+			policy:  terminationPolicyRandom,
+			servers: []*hcloud.Server{newServer, oldServer},
+			wantIDs: []int64{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := append([]*hcloud.Server{}, tt.servers...)
+			sortServersForTermination(servers, tt.policy)
+
+			if len(servers) != len(tt.wantIDs) {
+				t.Fatalf("got %d servers, want %d", len(servers), len(tt.wantIDs))
+			}
+			for i, server := range servers {
+				if server.ID != tt.wantIDs[i] {
+					t.Errorf("position %d: got server %d, want %d", i, server.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUtilizationPercent(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *hcloud.Server
+		want   int
+	}{
+		{name: "no label", server: &hcloud.Server{}, want: 100},
+		{name: "valid label", server: &hcloud.Server{Labels: map[string]string{"hcloud/utilization": "42"}}, want: 42},
+		{name: "unparsable label", server: &hcloud.Server{Labels: map[string]string{"hcloud/utilization": "n/a"}}, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utilizationPercent(tt.server); got != tt.want {
+				t.Errorf("utilizationPercent() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}